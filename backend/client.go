@@ -3,19 +3,32 @@ package backend
 import (
 	"context"
 	"net/url"
+	"time"
 
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 
 	"github.com/mercari/grpc-http-proxy"
+	"github.com/mercari/grpc-http-proxy/breaker"
+	"github.com/mercari/grpc-http-proxy/errors"
 )
 
+// defaultBreakers is the package-level breaker registry used by Client instances that
+// are not given one of their own
+var defaultBreakers = breaker.NewRegistry(breaker.DefaultPolicy)
+
 // Client is a dynamic gRPC client that performs reflection
 type Client struct {
-	logger *zap.Logger
+	logger      *zap.Logger
+	cache       *DescriptorCache
+	breakers    *breaker.Registry
+	retryPolicy RetryPolicy
+	target      *url.URL
 	*clientConn
 	*reflectionClient
 	*serviceDescriptor
 	*methodDescriptor
+	serviceName   string
 	InputMessage  *message
 	OutputMessage *message
 	*stub
@@ -27,10 +40,26 @@ func (c *Client) Err() error {
 	return c.err
 }
 
-// NewClient creates a new client
-func NewClient(l *zap.Logger) *Client {
+// NewClient creates a new client that resolves gRPC reflection through cache, retries
+// failed calls per retryPolicy, and trips a per-upstream circuit breaker in breakers
+// after repeated failures. A nil cache or breakers, or a zero-value retryPolicy, fall
+// back to this package's defaults, which are shared by every Client that does not
+// provide its own.
+func NewClient(l *zap.Logger, cache *DescriptorCache, breakers *breaker.Registry, retryPolicy RetryPolicy) *Client {
+	if cache == nil {
+		cache = defaultDescriptorCache
+	}
+	if breakers == nil {
+		breakers = defaultBreakers
+	}
+	if retryPolicy == (RetryPolicy{}) {
+		retryPolicy = DefaultRetryPolicy
+	}
 	return &Client{
 		logger:            l,
+		cache:             cache,
+		breakers:          breakers,
+		retryPolicy:       retryPolicy,
 		clientConn:        &clientConn{},
 		reflectionClient:  &reflectionClient{},
 		serviceDescriptor: &serviceDescriptor{},
@@ -46,6 +75,7 @@ func (c *Client) Connect(ctx context.Context, target *url.URL) {
 	if c.err != nil {
 		return
 	}
+	c.target = target
 	cc, err := newClientConn(ctx, target)
 	c.clientConn = cc
 	c.err = err
@@ -58,6 +88,9 @@ func (c *Client) CloseConn() {
 		return
 	}
 	c.err = c.clientConn.close()
+	if c.err == nil {
+		c.cache.InvalidateTarget(c.target)
+	}
 	return
 }
 
@@ -70,6 +103,14 @@ func (c *Client) newReflectionClient() {
 }
 
 func (c *Client) resolveService(ctx context.Context, serviceName string) {
+	if c.err != nil {
+		return
+	}
+	c.serviceName = serviceName
+	if sd, ok := c.cache.Get(c.target, serviceName); ok {
+		c.serviceDescriptor = sd
+		return
+	}
 	c.newReflectionClient()
 	if c.err != nil {
 		return
@@ -77,6 +118,9 @@ func (c *Client) resolveService(ctx context.Context, serviceName string) {
 	sd, err := c.reflectionClient.resolveService(ctx, serviceName)
 	c.err = err
 	c.serviceDescriptor = sd
+	if err == nil {
+		c.cache.Set(c.target, serviceName, sd)
+	}
 }
 
 func (c *Client) findMethodByName(name string) {
@@ -138,9 +182,64 @@ func (c *Client) invokeRPC(
 	m, err := c.stub.invokeRPC(ctx, c.methodDescriptor, c.InputMessage, md)
 	c.err = err
 	c.OutputMessage = m
+	c.invalidateIfUnimplemented()
 	return
 }
 
+// invalidateIfUnimplemented evicts the cached descriptor used for this call if the
+// upstream reported that it no longer implements the service, so that the next call
+// re-resolves it through reflection instead of repeating the same failure
+func (c *Client) invalidateIfUnimplemented() {
+	ge, ok := c.err.(*errors.GRPCError)
+	if !ok || codes.Code(ge.StatusCode) != codes.Unimplemented {
+		return
+	}
+	c.cache.Invalidate(c.target, c.serviceName)
+}
+
+// invokeRPCWithRetry calls invokeRPC, retrying per c.retryPolicy on retryable gRPC
+// codes, and records every attempt's outcome against the target's circuit breaker. If
+// the breaker is already open, the call fails fast with a synthesized Unavailable
+// error without going to the network at all.
+func (c *Client) invokeRPCWithRetry(ctx context.Context, md *proxy.Metadata) {
+	if c.err != nil {
+		return
+	}
+
+	b := c.breakers.For(c.target.String())
+	attempts := c.retryPolicy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if !b.Allow() {
+			c.err = &errors.GRPCError{
+				StatusCode: int(codes.Unavailable),
+				Message:    "circuit breaker open for upstream " + c.target.String(),
+			}
+			return
+		}
+
+		c.err = nil
+		attemptCtx, cancel := perAttemptDeadline(ctx, attempts-attempt)
+		c.invokeRPC(attemptCtx, md)
+		cancel()
+
+		if c.err == nil {
+			b.Success()
+			return
+		}
+		b.Failure()
+
+		ge, ok := c.err.(*errors.GRPCError)
+		if !ok || attempt == attempts-1 || !c.retryPolicy.retryable(codes.Code(ge.StatusCode)) {
+			return
+		}
+		time.Sleep(c.retryPolicy.backoff(attempt))
+	}
+}
+
 // Call performs the gRPC call after doing reflection to obtain type information
 func (c *Client) Call(ctx context.Context,
 	serviceName, methodName string,
@@ -149,7 +248,7 @@ func (c *Client) Call(ctx context.Context,
 ) (proxy.GRPCResponse, error) {
 	c.loadDescriptors(ctx, serviceName, methodName)
 	c.unmarshalInputMessage(message)
-	c.invokeRPC(ctx, md)
+	c.invokeRPCWithRetry(ctx, md)
 	response := c.marshalOutputMessage()
 	return response, c.err
 }
@@ -0,0 +1,184 @@
+package backend
+
+import (
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// descriptorCacheKey identifies a cached service descriptor by upstream target and
+// gRPC service name.
+type descriptorCacheKey struct {
+	target  string
+	service string
+}
+
+type descriptorCacheEntry struct {
+	descriptor *serviceDescriptor
+	expiresAt  time.Time
+	// lastAccess is a UnixNano timestamp, read and written atomically so Get can
+	// update it without taking a lock
+	lastAccess int64
+}
+
+// DescriptorCache caches the result of reflecting against an upstream, keyed by
+// (target URL, service name), so that Client.Call does not have to perform a full
+// reflection handshake on every request. Descriptors are immutable protoreflect
+// values, so Get never takes a lock; it is backed by a sync.Map. A DescriptorCache is
+// safe to share across Client instances, and should be: that is what lets a hot
+// (target, service) pair amortize the reflection cost across every Client that talks
+// to it. Once the cache grows past maxEntries, it evicts approximately the
+// least-recently-used entry, tracked via an atomically-updated per-entry timestamp
+// rather than a true LRU list, so that Get still never takes a lock.
+type DescriptorCache struct {
+	entries    sync.Map // descriptorCacheKey -> *descriptorCacheEntry
+	ttl        time.Duration
+	maxEntries int64
+	size       int64
+	closeCh    chan struct{}
+
+	Hits   prometheus.Counter
+	Misses prometheus.Counter
+}
+
+// defaultDescriptorCache is the package-level cache used by Client instances that are
+// not given one of their own
+var defaultDescriptorCache = NewDescriptorCache(5*time.Minute, 1000)
+
+// NewDescriptorCache creates a DescriptorCache holding approximately at most maxEntries
+// descriptors, each considered fresh for ttl. A background goroutine sweeps expired
+// entries every ttl so that they do not linger in the map; Get still treats an entry
+// past its ttl as a miss until the sweep removes it.
+func NewDescriptorCache(ttl time.Duration, maxEntries int) *DescriptorCache {
+	c := &DescriptorCache{
+		ttl:        ttl,
+		maxEntries: int64(maxEntries),
+		closeCh:    make(chan struct{}),
+		Hits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "grpc_http_proxy",
+			Subsystem: "descriptor_cache",
+			Name:      "hits_total",
+			Help:      "Number of descriptor cache lookups that found a usable entry.",
+		}),
+		Misses: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "grpc_http_proxy",
+			Subsystem: "descriptor_cache",
+			Name:      "misses_total",
+			Help:      "Number of descriptor cache lookups that found no usable entry.",
+		}),
+	}
+	go c.sweep()
+	return c
+}
+
+func cacheKey(target *url.URL, serviceName string) descriptorCacheKey {
+	return descriptorCacheKey{target: target.String(), service: serviceName}
+}
+
+// Get returns the cached descriptor for (target, serviceName), if one is present and
+// has not expired
+func (c *DescriptorCache) Get(target *url.URL, serviceName string) (*serviceDescriptor, bool) {
+	v, ok := c.entries.Load(cacheKey(target, serviceName))
+	if !ok {
+		c.Misses.Inc()
+		return nil, false
+	}
+	e := v.(*descriptorCacheEntry)
+	if time.Now().After(e.expiresAt) {
+		c.Misses.Inc()
+		return nil, false
+	}
+	atomic.StoreInt64(&e.lastAccess, time.Now().UnixNano())
+	c.Hits.Inc()
+	return e.descriptor, true
+}
+
+// Set caches sd as the descriptor for (target, serviceName)
+func (c *DescriptorCache) Set(target *url.URL, serviceName string, sd *serviceDescriptor) {
+	k := cacheKey(target, serviceName)
+	e := &descriptorCacheEntry{
+		descriptor: sd,
+		expiresAt:  time.Now().Add(c.ttl),
+		lastAccess: time.Now().UnixNano(),
+	}
+	if _, loaded := c.entries.LoadOrStore(k, e); loaded {
+		c.entries.Store(k, e)
+		return
+	}
+	if atomic.AddInt64(&c.size, 1) > c.maxEntries {
+		c.evictOne()
+	}
+}
+
+// Invalidate removes the cached descriptor for (target, serviceName), e.g. because the
+// upstream returned Unimplemented for it
+func (c *DescriptorCache) Invalidate(target *url.URL, serviceName string) {
+	c.delete(cacheKey(target, serviceName))
+}
+
+// InvalidateTarget removes every cached descriptor for target, e.g. because its
+// connection was torn down
+func (c *DescriptorCache) InvalidateTarget(target *url.URL) {
+	t := target.String()
+	c.entries.Range(func(k, _ interface{}) bool {
+		if key := k.(descriptorCacheKey); key.target == t {
+			c.delete(key)
+		}
+		return true
+	})
+}
+
+func (c *DescriptorCache) delete(k descriptorCacheKey) {
+	if _, loaded := c.entries.LoadAndDelete(k); loaded {
+		atomic.AddInt64(&c.size, -1)
+	}
+}
+
+// Close stops the cache's background expiry sweep
+func (c *DescriptorCache) Close() {
+	close(c.closeCh)
+}
+
+func (c *DescriptorCache) sweep() {
+	ticker := time.NewTicker(c.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case now := <-ticker.C:
+			c.entries.Range(func(k, v interface{}) bool {
+				if now.After(v.(*descriptorCacheEntry).expiresAt) {
+					c.delete(k.(descriptorCacheKey))
+				}
+				return true
+			})
+		}
+	}
+}
+
+// evictOne drops the entry with the oldest lastAccess once the cache has grown past
+// maxEntries, found by scanning every entry. This approximates LRU, but is not a true
+// one: lastAccess is
+// read without synchronizing against concurrent Gets of other entries, so the entry
+// picked is the oldest as of when the scan observed it, not necessarily at the instant
+// evictOne decides to delete it. That approximation is the tradeoff for Get never
+// taking a lock.
+func (c *DescriptorCache) evictOne() {
+	var oldestKey descriptorCacheKey
+	var oldestAccess int64 = -1
+	c.entries.Range(func(k, v interface{}) bool {
+		a := atomic.LoadInt64(&v.(*descriptorCacheEntry).lastAccess)
+		if oldestAccess == -1 || a < oldestAccess {
+			oldestKey = k.(descriptorCacheKey)
+			oldestAccess = a
+		}
+		return true
+	})
+	if oldestAccess != -1 {
+		c.delete(oldestKey)
+	}
+}
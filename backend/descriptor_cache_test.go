@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDescriptorCache_GetSetRoundTrip(t *testing.T) {
+	c := NewDescriptorCache(time.Minute, 10)
+	defer c.Close()
+
+	target := &url.URL{Scheme: "dns", Host: "a:1"}
+	sd := &serviceDescriptor{}
+	c.Set(target, "svc", sd)
+
+	got, ok := c.Get(target, "svc")
+	if !ok {
+		t.Fatal("expected a cache hit after Set")
+	}
+	if got != sd {
+		t.Fatalf("got %v, want %v", got, sd)
+	}
+}
+
+func TestDescriptorCache_ExpiresAfterTTL(t *testing.T) {
+	c := NewDescriptorCache(time.Millisecond, 10)
+	defer c.Close()
+
+	target := &url.URL{Scheme: "dns", Host: "a:1"}
+	c.Set(target, "svc", &serviceDescriptor{})
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.Get(target, "svc"); ok {
+		t.Fatal("expected entry to have expired")
+	}
+}
+
+func TestDescriptorCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewDescriptorCache(time.Minute, 2)
+	defer c.Close()
+
+	old := &url.URL{Scheme: "dns", Host: "old:1"}
+	fresh := &url.URL{Scheme: "dns", Host: "fresh:1"}
+	c.Set(old, "svc", &serviceDescriptor{})
+	c.Set(fresh, "svc", &serviceDescriptor{})
+
+	// touch fresh so its lastAccess is newer than old's
+	time.Sleep(time.Millisecond)
+	if _, ok := c.Get(fresh, "svc"); !ok {
+		t.Fatal("expected a hit for fresh before triggering eviction")
+	}
+
+	time.Sleep(time.Millisecond)
+	third := &url.URL{Scheme: "dns", Host: "third:1"}
+	c.Set(third, "svc", &serviceDescriptor{}) // pushes size past maxEntries, evicting old
+
+	if _, ok := c.Get(old, "svc"); ok {
+		t.Fatal("expected the least-recently-used entry to have been evicted")
+	}
+	if _, ok := c.Get(fresh, "svc"); !ok {
+		t.Fatal("expected the recently-accessed entry to survive eviction")
+	}
+}
@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// RetryPolicy bounds how Client retries a call after a transient upstream failure.
+// Only codes that are safe to retry are eligible: Unavailable and ResourceExhausted
+// always, DeadlineExceeded only if explicitly opted into.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times a call is attempted, including the
+	// first one. A value <= 1 disables retries.
+	MaxAttempts int
+	// BaseBackoff and MaxBackoff bound the exponential backoff applied between
+	// attempts. Each wait is chosen uniformly from [0, backoff), so that concurrent
+	// callers retrying the same failure do not retry in lockstep.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// RetryDeadlineExceeded additionally retries on codes.DeadlineExceeded. This is
+	// off by default: a DeadlineExceeded from the upstream often means the caller's
+	// own deadline was too tight, and retrying burns the remaining budget rather
+	// than routing around a flaky backend.
+	RetryDeadlineExceeded bool
+}
+
+// DefaultRetryPolicy is a reasonable default for production use
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseBackoff: 50 * time.Millisecond,
+	MaxBackoff:  2 * time.Second,
+}
+
+func (p RetryPolicy) retryable(code codes.Code) bool {
+	switch code {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	case codes.DeadlineExceeded:
+		return p.RetryDeadlineExceeded
+	default:
+		return false
+	}
+}
+
+// backoff returns a jittered backoff duration for the given zero-based attempt number
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseBackoff <= 0 {
+		return 0
+	}
+	d := p.BaseBackoff << uint(attempt)
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// perAttemptDeadline derives a deadline for a single attempt from ctx's own deadline,
+// dividing the time remaining evenly across the attempts left, so a slow upstream
+// cannot consume the whole budget on one attempt and starve the retries meant to
+// route around it.
+func perAttemptDeadline(ctx context.Context, attemptsLeft int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || attemptsLeft <= 1 {
+		return context.WithCancel(ctx)
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, remaining/time.Duration(attemptsLeft))
+}
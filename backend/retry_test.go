@@ -0,0 +1,92 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	cases := []struct {
+		name                  string
+		retryDeadlineExceeded bool
+		code                  codes.Code
+		expected              bool
+	}{
+		{name: "unavailable", code: codes.Unavailable, expected: true},
+		{name: "resource exhausted", code: codes.ResourceExhausted, expected: true},
+		{name: "deadline exceeded, opted out", code: codes.DeadlineExceeded, expected: false},
+		{name: "deadline exceeded, opted in", retryDeadlineExceeded: true, code: codes.DeadlineExceeded, expected: true},
+		{name: "not found", code: codes.NotFound, expected: false},
+		{name: "ok", code: codes.OK, expected: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := RetryPolicy{RetryDeadlineExceeded: tc.retryDeadlineExceeded}
+			if got, want := p.retryable(tc.code), tc.expected; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff_BoundedByMaxBackoff(t *testing.T) {
+	p := RetryPolicy{BaseBackoff: 50 * time.Millisecond, MaxBackoff: 200 * time.Millisecond}
+	for attempt := 0; attempt < 10; attempt++ {
+		d := p.backoff(attempt)
+		if d < 0 || d > p.MaxBackoff {
+			t.Fatalf("attempt %d: backoff %s out of bounds [0, %s]", attempt, d, p.MaxBackoff)
+		}
+	}
+}
+
+func TestRetryPolicy_Backoff_ZeroBaseBackoffIsZero(t *testing.T) {
+	p := RetryPolicy{MaxBackoff: 200 * time.Millisecond}
+	if got := p.backoff(0); got != 0 {
+		t.Fatalf("got %s, want 0", got)
+	}
+}
+
+func TestPerAttemptDeadline_NoDeadlineOnCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	attemptCtx, attemptCancel := perAttemptDeadline(ctx, 3)
+	defer attemptCancel()
+	if _, ok := attemptCtx.Deadline(); ok {
+		t.Fatal("expected no deadline to be derived from a context with none")
+	}
+}
+
+func TestPerAttemptDeadline_DividesRemainingTimeAcrossAttempts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	attemptCtx, attemptCancel := perAttemptDeadline(ctx, 4)
+	defer attemptCancel()
+
+	deadline, ok := attemptCtx.Deadline()
+	if !ok {
+		t.Fatal("expected a deadline to be derived")
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 0 || remaining > 30*time.Millisecond {
+		t.Fatalf("got %s remaining, want roughly 100ms/4 = 25ms", remaining)
+	}
+}
+
+func TestPerAttemptDeadline_LastAttemptGetsFullRemainingBudget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	attemptCtx, attemptCancel := perAttemptDeadline(ctx, 1)
+	defer attemptCancel()
+
+	deadline, ok := attemptCtx.Deadline()
+	if !ok {
+		t.Fatal("expected a deadline to be derived")
+	}
+	remaining := time.Until(deadline)
+	if remaining <= 30*time.Millisecond || remaining > 100*time.Millisecond {
+		t.Fatalf("got %s remaining, want roughly the full 100ms budget", remaining)
+	}
+}
@@ -0,0 +1,167 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/mercari/grpc-http-proxy"
+	"github.com/mercari/grpc-http-proxy/errors"
+)
+
+func unsupportedStreamingMode(message string) *errors.GRPCError {
+	return &errors.GRPCError{
+		StatusCode: int(codes.Unimplemented),
+		Message:    message,
+	}
+}
+
+// CallStream performs a streaming gRPC call, writing each response message to w as it
+// arrives rather than buffering the whole stream in memory, and, for client-streaming
+// methods, reading request messages from body as newline-delimited JSON rather than
+// requiring the caller to have them all in hand up front.
+//
+// Bidirectional streaming is left unsupported in this first pass: it is rejected with
+// an explicit error rather than silently falling back to unary behavior, so that
+// callers can distinguish "not implemented yet" from a real upstream failure.
+func (c *Client) CallStream(ctx context.Context,
+	serviceName, methodName string,
+	body io.Reader,
+	w proxy.GRPCResponseWriter,
+	md *proxy.Metadata,
+) error {
+	c.loadDescriptors(ctx, serviceName, methodName)
+	if c.err != nil {
+		return c.err
+	}
+
+	serverStreaming := c.methodDescriptor.IsServerStreaming()
+	clientStreaming := c.methodDescriptor.IsClientStreaming()
+
+	switch {
+	case serverStreaming && clientStreaming:
+		return unsupportedStreamingMode("bidirectional streaming is not supported")
+	case serverStreaming:
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		c.unmarshalInputMessage(b)
+		if c.err != nil {
+			return c.err
+		}
+		return c.invokeServerStream(ctx, w, md)
+	case clientStreaming:
+		return c.invokeClientStream(ctx, body, w, md)
+	default:
+		return unsupportedStreamingMode("CallStream was used for a unary method; use Call instead")
+	}
+}
+
+// breakerUnavailable synthesizes the error invokeRPCWithRetry returns when a circuit
+// breaker is open, so a stream that is refused for the same reason looks the same to
+// callers.
+func (c *Client) breakerUnavailable() *errors.GRPCError {
+	return &errors.GRPCError{
+		StatusCode: int(codes.Unavailable),
+		Message:    "circuit breaker open for upstream " + c.target.String(),
+	}
+}
+
+// invokeServerStream invokes c.methodDescriptor, already known to be server-streaming,
+// with c.InputMessage, and writes each response message to w as it is received. Like
+// invokeRPCWithRetry, it consults the target's circuit breaker before making the call.
+// Only the stub's own calls (opening the stream, receiving a message) count as breaker
+// outcomes: marshaling a received message or writing it to w can fail for reasons that
+// have nothing to do with the upstream's health (e.g. the downstream HTTP client going
+// away), and must not trip a breaker meant to track that backend.
+func (c *Client) invokeServerStream(ctx context.Context, w proxy.GRPCResponseWriter, md *proxy.Metadata) error {
+	b := c.breakers.For(c.target.String())
+	if !b.Allow() {
+		return c.breakerUnavailable()
+	}
+
+	c.newStub()
+	if c.err != nil {
+		return c.err
+	}
+	ss, err := c.stub.invokeServerStream(ctx, c.methodDescriptor, c.InputMessage, md)
+	if err != nil {
+		b.Failure()
+		return err
+	}
+	for {
+		out, err := ss.recv()
+		if err == io.EOF {
+			b.Success()
+			return nil
+		}
+		if err != nil {
+			b.Failure()
+			return err
+		}
+		bs, err := out.marshalJSON()
+		if err != nil {
+			return err
+		}
+		if err := w.WriteMessage(bs); err != nil {
+			return err
+		}
+	}
+}
+
+// invokeClientStream invokes c.methodDescriptor, already known to be client-streaming,
+// sending one request message per newline-delimited JSON frame decoded from body, and
+// writes the single response message to w once the upstream has seen them all. Like
+// invokeServerStream, it only records a breaker outcome for the stub's own calls
+// (opening the stream, sending a message, closing and receiving the response), not for
+// decoding the caller's request body or writing the response back out.
+func (c *Client) invokeClientStream(ctx context.Context, body io.Reader, w proxy.GRPCResponseWriter, md *proxy.Metadata) error {
+	b := c.breakers.For(c.target.String())
+	if !b.Allow() {
+		return c.breakerUnavailable()
+	}
+
+	c.newStub()
+	if c.err != nil {
+		return c.err
+	}
+	cs, err := c.stub.invokeClientStream(ctx, c.methodDescriptor, md)
+	if err != nil {
+		b.Failure()
+		return err
+	}
+
+	dec := json.NewDecoder(body)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		in := c.methodDescriptor.getInputType().newMessage()
+		if err := in.unmarshalJSON(raw); err != nil {
+			return err
+		}
+		if err := cs.send(in); err != nil {
+			b.Failure()
+			return err
+		}
+	}
+
+	out, err := cs.closeAndReceive()
+	if err != nil {
+		b.Failure()
+		return err
+	}
+	b.Success()
+	bs, err := out.marshalJSON()
+	if err != nil {
+		return err
+	}
+	return w.WriteMessage(bs)
+}
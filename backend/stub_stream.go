@@ -0,0 +1,84 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	grpcmetadata "google.golang.org/grpc/metadata"
+
+	"github.com/mercari/grpc-http-proxy"
+)
+
+func outgoingContext(ctx context.Context, metadata *proxy.Metadata) context.Context {
+	if metadata == nil {
+		return ctx
+	}
+	return grpcmetadata.NewOutgoingContext(ctx, grpcmetadata.MD(*metadata))
+}
+
+// serverStream wraps a grpcdynamic.ServerStream so that callers only have to deal with
+// this package's message type
+type serverStream struct {
+	stream     *grpcdynamic.ServerStream
+	outputType *messageDescriptor
+}
+
+func (s *serverStream) recv() (*message, error) {
+	m, err := s.stream.RecvMsg()
+	if err != nil {
+		return nil, err
+	}
+	out := s.outputType.newMessage()
+	out.msg = m
+	return out, nil
+}
+
+// clientStream wraps a grpcdynamic.ClientStream so that callers only have to deal with
+// this package's message type
+type clientStream struct {
+	stream     *grpcdynamic.ClientStream
+	outputType *messageDescriptor
+}
+
+func (s *clientStream) send(m *message) error {
+	return s.stream.SendMsg(m.msg)
+}
+
+func (s *clientStream) closeAndReceive() (*message, error) {
+	m, err := s.stream.CloseAndReceive()
+	if err != nil {
+		return nil, err
+	}
+	out := s.outputType.newMessage()
+	out.msg = m
+	return out, nil
+}
+
+// invokeServerStream opens a server-streaming RPC for md, sending in as the single
+// request message
+func (s *stub) invokeServerStream(
+	ctx context.Context,
+	md *methodDescriptor,
+	in *message,
+	metadata *proxy.Metadata,
+) (*serverStream, error) {
+	str, err := s.stub.InvokeRpcServerStream(outgoingContext(ctx, metadata), md.desc, in.msg)
+	if err != nil {
+		return nil, err
+	}
+	return &serverStream{stream: str, outputType: md.getOutputType()}, nil
+}
+
+// invokeClientStream opens a client-streaming RPC for md. Request messages are sent on
+// the returned clientStream as they become available.
+func (s *stub) invokeClientStream(
+	ctx context.Context,
+	md *methodDescriptor,
+	metadata *proxy.Metadata,
+) (*clientStream, error) {
+	str, err := s.stub.InvokeRpcClientStream(outgoingContext(ctx, metadata), md.desc)
+	if err != nil {
+		return nil, err
+	}
+	return &clientStream{stream: str, outputType: md.getOutputType()}, nil
+}
@@ -0,0 +1,171 @@
+// Package breaker implements a per-upstream circuit breaker used to stop retrying a
+// backend that is already known to be failing, instead of hitting it on every request.
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State is one of the three states a Breaker can be in
+type State int
+
+const (
+	// Closed lets all calls through, counting failures towards tripping
+	Closed State = iota
+	// Open refuses all calls until Policy.OpenTimeout has elapsed
+	Open
+	// HalfOpen lets a single probe call through to decide whether to close again
+	HalfOpen
+)
+
+// Policy configures when a Breaker trips open and how it recovers
+type Policy struct {
+	// FailureThreshold is the number of consecutive failures after which the
+	// breaker trips to Open. Zero disables this trigger.
+	FailureThreshold int
+	// ErrorRatioThreshold additionally trips the breaker once the ratio of failures
+	// to total calls observed within Window exceeds it. Zero disables this trigger.
+	ErrorRatioThreshold float64
+	// Window bounds how far back calls are counted towards ErrorRatioThreshold
+	Window time.Duration
+	// OpenTimeout is how long the breaker stays Open before allowing a single probe
+	// call through in HalfOpen
+	OpenTimeout time.Duration
+}
+
+// DefaultPolicy is a reasonable default for production use
+var DefaultPolicy = Policy{
+	FailureThreshold:    5,
+	ErrorRatioThreshold: 0.5,
+	Window:              30 * time.Second,
+	OpenTimeout:         10 * time.Second,
+}
+
+type callResult struct {
+	at      time.Time
+	success bool
+}
+
+// Breaker is a circuit breaker for a single upstream. It is safe for concurrent use.
+type Breaker struct {
+	policy Policy
+
+	mu       sync.Mutex
+	state    State
+	openedAt time.Time
+	probing  bool
+	calls    []callResult
+	// consecutiveFailures counts failures since the last success, independently of
+	// Window
+	consecutiveFailures int
+}
+
+// NewBreaker creates a Breaker governed by policy, starting Closed
+func NewBreaker(policy Policy) *Breaker {
+	return &Breaker{policy: policy}
+}
+
+// Open reports whether the breaker is Open and still within OpenTimeout, without
+// reserving the half-open probe slot. A HalfOpen breaker whose probe is in flight is
+// not reported as open, although Allow refuses calls to it. Callers that actually intend to make the call should use
+// Allow instead; Open is meant for load-balancer-style filtering of candidate
+// upstreams before one is chosen.
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == Open && time.Since(b.openedAt) < b.policy.OpenTimeout
+}
+
+// Allow reports whether a call should be let through right now. When the breaker is
+// Open but OpenTimeout has elapsed, Allow transitions it to HalfOpen and reserves the
+// single probe slot for the caller, who must report the outcome via Success or
+// Failure.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Closed:
+		return true
+	case Open:
+		if time.Since(b.openedAt) < b.policy.OpenTimeout {
+			return false
+		}
+		b.state = HalfOpen
+		b.probing = true
+		return true
+	case HalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// Success records a successful call. A successful probe in HalfOpen closes the
+// breaker again.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(true)
+	b.consecutiveFailures = 0
+	if b.state == HalfOpen {
+		b.state = Closed
+		b.probing = false
+	}
+}
+
+// Failure records a failed call. A failed probe in HalfOpen re-opens the breaker; a
+// run of failures in Closed trips it.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.record(false)
+	b.consecutiveFailures++
+	if b.state == HalfOpen {
+		b.trip()
+		return
+	}
+	if b.state == Closed && b.tripThresholdExceeded() {
+		b.trip()
+	}
+}
+
+func (b *Breaker) trip() {
+	b.state = Open
+	b.openedAt = time.Now()
+	b.probing = false
+}
+
+func (b *Breaker) record(success bool) {
+	now := time.Now()
+	b.calls = append(b.calls, callResult{at: now, success: success})
+	cutoff := now.Add(-b.policy.Window)
+	i := 0
+	for ; i < len(b.calls); i++ {
+		if b.calls[i].at.After(cutoff) {
+			break
+		}
+	}
+	b.calls = b.calls[i:]
+}
+
+func (b *Breaker) tripThresholdExceeded() bool {
+	if b.policy.FailureThreshold > 0 && b.consecutiveFailures >= b.policy.FailureThreshold {
+		return true
+	}
+	if b.policy.ErrorRatioThreshold > 0 && len(b.calls) > 0 {
+		failures := 0
+		for _, c := range b.calls {
+			if !c.success {
+				failures++
+			}
+		}
+		if float64(failures)/float64(len(b.calls)) > b.policy.ErrorRatioThreshold {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker_TripsAfterConsecutiveFailures(t *testing.T) {
+	b := NewBreaker(Policy{FailureThreshold: 3, OpenTimeout: time.Minute})
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatalf("expected breaker to allow call %d before tripping", i)
+		}
+		b.Failure()
+	}
+	if b.Open() {
+		t.Fatal("breaker tripped before reaching FailureThreshold")
+	}
+	if !b.Allow() {
+		t.Fatal("expected breaker to allow the call that trips it")
+	}
+	b.Failure()
+	if !b.Open() {
+		t.Fatal("expected breaker to be open after FailureThreshold consecutive failures")
+	}
+	if b.Allow() {
+		t.Fatal("expected breaker to refuse calls while open")
+	}
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	b := NewBreaker(Policy{FailureThreshold: 1, OpenTimeout: time.Millisecond})
+	if !b.Allow() {
+		t.Fatal("expected closed breaker to allow the first call")
+	}
+	b.Failure()
+	if b.Allow() {
+		t.Fatal("expected breaker to refuse calls immediately after tripping")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("expected breaker to allow a single probe once OpenTimeout has elapsed")
+	}
+	if b.Allow() {
+		t.Fatal("expected breaker to refuse a second concurrent probe while one is in flight")
+	}
+}
+
+func TestBreaker_SuccessfulProbeCloses(t *testing.T) {
+	b := NewBreaker(Policy{FailureThreshold: 1, OpenTimeout: time.Millisecond})
+	b.Allow()
+	b.Failure()
+	time.Sleep(5 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("expected probe to be allowed")
+	}
+	b.Success()
+	if b.Open() {
+		t.Fatal("expected breaker to close after a successful probe")
+	}
+	if !b.Allow() {
+		t.Fatal("expected closed breaker to allow calls")
+	}
+}
+
+func TestBreaker_FailedProbeReopens(t *testing.T) {
+	b := NewBreaker(Policy{FailureThreshold: 1, OpenTimeout: time.Millisecond})
+	b.Allow()
+	b.Failure()
+	time.Sleep(5 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("expected probe to be allowed")
+	}
+	b.Failure()
+	if !b.Open() {
+		t.Fatal("expected breaker to re-open after a failed probe")
+	}
+}
+
+func TestBreaker_ErrorRatioThreshold(t *testing.T) {
+	b := NewBreaker(Policy{ErrorRatioThreshold: 0.5, Window: time.Minute, OpenTimeout: time.Minute})
+	b.Allow()
+	b.Success()
+	b.Allow()
+	b.Failure()
+	if b.Open() {
+		t.Fatal("breaker tripped at exactly the threshold ratio, not above it")
+	}
+	b.Allow()
+	b.Failure()
+	if !b.Open() {
+		t.Fatal("expected breaker to trip once the failure ratio exceeds ErrorRatioThreshold")
+	}
+}
@@ -0,0 +1,35 @@
+package breaker
+
+import "sync"
+
+// Registry holds one Breaker per upstream URL, created lazily the first time that URL
+// is seen. A Registry is safe to share across every Client and LoadBalancer that talks
+// to the same set of upstreams, which is what lets a breaker tripped by one caller
+// protect every other caller of that upstream too.
+type Registry struct {
+	policy Policy
+
+	mu    sync.Mutex
+	byURL map[string]*Breaker
+}
+
+// NewRegistry creates a Registry whose breakers are all governed by policy
+func NewRegistry(policy Policy) *Registry {
+	return &Registry{
+		policy: policy,
+		byURL:  make(map[string]*Breaker),
+	}
+}
+
+// For returns the Breaker for url, creating it with the Registry's Policy if this is
+// the first time url is seen
+func (r *Registry) For(url string) *Breaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.byURL[url]
+	if !ok {
+		b = NewBreaker(r.policy)
+		r.byURL[url] = b
+	}
+	return b
+}
@@ -5,22 +5,132 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	"github.com/mercari/grpc-http-proxy/backend"
+	"github.com/mercari/grpc-http-proxy/breaker"
 	"github.com/mercari/grpc-http-proxy/config"
 	"github.com/mercari/grpc-http-proxy/http"
 	"github.com/mercari/grpc-http-proxy/log"
 	"github.com/mercari/grpc-http-proxy/source"
 )
 
+var discovery = flag.String("discovery", "", "service discovery backend to use. "+
+	"One of: static, kubernetes, consul, etcd, dns. If unset, defaults to static when "+
+	"--mapping-file is set, and to kubernetes otherwise")
 var mappingFile = flag.String("mapping-file", "", "mapping file for grpc service names "+
-	"to server host names. Kubernetes API will be used for service discovery if this is unspecified")
+	"to server host names. Used by the static discovery backend")
+var loadBalancer = flag.String("load-balancer", "round-robin", "strategy used to pick a backend "+
+	"when multiple are registered for the same (service, version) pair. One of: round-robin, random, weighted")
+var loadBalancerWeights = flag.String("load-balancer-weights", "", "comma-separated url=weight pairs "+
+	"used when --load-balancer=weighted, e.g. \"10.0.0.1:50051=2,10.0.0.2:50051=1\". URLs not listed "+
+	"default to weight 1")
+var consulAddr = flag.String("consul-addr", "", "address of the Consul agent. "+
+	"Used by the consul discovery backend")
+var etcdEndpoints = flag.String("etcd-endpoints", "", "comma-separated list of etcd endpoints. "+
+	"Used by the etcd discovery backend")
+var etcdPrefix = flag.String("etcd-prefix", "/grpc-http-proxy/services", "etcd key prefix watched "+
+	"for service registrations. Used by the etcd discovery backend")
+var dnsDomain = flag.String("dns-domain", "", "domain SRV records for watched services are looked up "+
+	"under. Used by the dns discovery backend")
+var dnsServices = flag.String("dns-services", "", "comma-separated list of gRPC service names to "+
+	"watch via DNS SRV lookups. Used by the dns discovery backend")
+var breakerFailureThreshold = flag.Int("breaker-failure-threshold", breaker.DefaultPolicy.FailureThreshold,
+	"number of consecutive failures against an upstream before its circuit breaker trips open")
+var breakerOpenTimeout = flag.Duration("breaker-open-timeout", breaker.DefaultPolicy.OpenTimeout,
+	"how long an open circuit breaker waits before allowing a single probe call through")
+var retryMaxAttempts = flag.Int("retry-max-attempts", backend.DefaultRetryPolicy.MaxAttempts,
+	"maximum number of attempts, including the first, for a call that fails with a retryable gRPC code")
+var retryMaxBackoff = flag.Duration("retry-max-backoff", backend.DefaultRetryPolicy.MaxBackoff,
+	"upper bound on the backoff between retried attempts")
+
+func newLoadBalancer(strategy, weights string) (source.LoadBalancer, error) {
+	switch strategy {
+	case "round-robin":
+		return source.NewRoundRobin(), nil
+	case "random":
+		return source.NewRandom(), nil
+	case "weighted":
+		w, err := parseLoadBalancerWeights(weights)
+		if err != nil {
+			return nil, err
+		}
+		return source.NewWeightedRandom(w), nil
+	default:
+		return nil, fmt.Errorf("unknown load balancer strategy %q", strategy)
+	}
+}
+
+// parseLoadBalancerWeights parses the comma-separated url=weight pairs accepted by
+// --load-balancer-weights into the map WeightedRandom expects
+func parseLoadBalancerWeights(s string) (map[string]int, error) {
+	weights := make(map[string]int)
+	if s == "" {
+		return weights, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid --load-balancer-weights entry %q, want url=weight", pair)
+		}
+		w, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight in --load-balancer-weights entry %q: %s", pair, err)
+		}
+		weights[kv[0]] = w
+	}
+	return weights, nil
+}
+
+func newDiscovery(backend string, lb source.LoadBalancer, logger *zap.Logger) (source.Discovery, error) {
+	switch backend {
+	case "static":
+		return source.NewStatic(logger, *mappingFile, lb), nil
+	case "kubernetes":
+		k8sConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create k8s config: %s", err)
+		}
+		k8sClient, err := kubernetes.NewForConfig(k8sConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create k8s client: %s", err)
+		}
+		return source.NewService(k8sClient, "", logger, lb), nil
+	case "consul":
+		return source.NewConsul(*consulAddr, lb, logger)
+	case "etcd":
+		endpoints := strings.Split(*etcdEndpoints, ",")
+		return source.NewEtcd(endpoints, *etcdPrefix, lb, logger)
+	case "dns":
+		services := strings.Split(*dnsServices, ",")
+		return source.NewDNS(*dnsDomain, services, lb, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown discovery backend %q", backend)
+	}
+}
+
+// discoveryBackend returns the discovery backend to use, honoring an explicit
+// --discovery flag. If --discovery was left unset, it preserves the old behavior of
+// picking static whenever --mapping-file is set, and kubernetes otherwise, so that
+// deployments that only ever passed -mapping-file=... keep working unchanged.
+func discoveryBackend() string {
+	if *discovery != "" {
+		return *discovery
+	}
+	if *mappingFile != "" {
+		return "static"
+	}
+	return "kubernetes"
+}
 
 func main() {
 	flag.Parse()
+	*discovery = discoveryBackend()
 	env, err := config.ReadFromEnv()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[ERROR] Failed to read environment variables: %s\n", err.Error())
@@ -31,30 +141,38 @@ func main() {
 		fmt.Fprintf(os.Stderr, "[ERROR] Failed to create logger: %s\n", err)
 		os.Exit(1)
 	}
-
-	var s *http.Server
-	if *mappingFile == "" {
-		k8sConfig, err := rest.InClusterConfig()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[ERROR] Failed to create k8s config: %s\n", err)
-			os.Exit(1)
-		}
-		k8sClient, err := kubernetes.NewForConfig(k8sConfig)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[ERROR] Failed to create k8s client: %s\n", err)
-			os.Exit(1)
-		}
-		d := source.NewService(k8sClient, "", logger)
-		stopCh := make(chan struct{})
-		d.Run(stopCh)
-		s = http.New(env.Token, d, logger)
-	} else {
-		d := source.NewStatic(logger, *mappingFile)
-		s = http.New(env.Token, d, logger)
+	lb, err := newLoadBalancer(*loadBalancer, *loadBalancerWeights)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] Failed to create load balancer: %s\n", err)
+		os.Exit(1)
+	}
+	breakers := breaker.NewRegistry(breaker.Policy{
+		FailureThreshold:    *breakerFailureThreshold,
+		ErrorRatioThreshold: breaker.DefaultPolicy.ErrorRatioThreshold,
+		Window:              breaker.DefaultPolicy.Window,
+		OpenTimeout:         *breakerOpenTimeout,
+	})
+	lb = source.NewBreakerAware(lb, breakers)
+	retryPolicy := backend.RetryPolicy{
+		MaxAttempts: *retryMaxAttempts,
+		BaseBackoff: backend.DefaultRetryPolicy.BaseBackoff,
+		MaxBackoff:  *retryMaxBackoff,
 	}
+	d, err := newDiscovery(*discovery, lb, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] Failed to create %s discovery backend: %s\n", *discovery, err)
+		os.Exit(1)
+	}
+
+	stopCh := make(chan struct{})
+	d.Run(stopCh)
+	defer close(stopCh)
+	s := http.New(env.Token, d, logger, breakers, retryPolicy)
+
 	logger.Info("starting grpc-http-proxy",
 		zap.String("log_level", env.LogLevel),
 		zap.Int16("port", env.Port),
+		zap.String("discovery", *discovery),
 	)
 
 	addr := fmt.Sprintf(":%d", env.Port)
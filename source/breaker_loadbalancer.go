@@ -0,0 +1,37 @@
+package source
+
+import (
+	"github.com/mercari/grpc-http-proxy"
+	"github.com/mercari/grpc-http-proxy/breaker"
+)
+
+// BreakerAware wraps another LoadBalancer, excluding backend URLs whose circuit
+// breaker is currently open from consideration before delegating the actual pick to
+// it. If every URL for a (service, version) has its breaker open, all of them are
+// offered anyway, so that Pick still returns a URL. A URL whose breaker has passed its
+// OpenTimeout is not reported open, so it is offered for its half-open probe without
+// this fallback.
+type BreakerAware struct {
+	inner    LoadBalancer
+	breakers *breaker.Registry
+}
+
+// NewBreakerAware creates a BreakerAware load balancer that filters candidates
+// through breakers before picking among them with inner
+func NewBreakerAware(inner LoadBalancer, breakers *breaker.Registry) *BreakerAware {
+	return &BreakerAware{inner: inner, breakers: breakers}
+}
+
+// Pick returns a URL chosen by inner, restricted to URLs whose breaker is not open
+func (b *BreakerAware) Pick(svc string, urls []proxy.ServiceURL) proxy.ServiceURL {
+	available := make([]proxy.ServiceURL, 0, len(urls))
+	for _, u := range urls {
+		if !b.breakers.For(u.String()).Open() {
+			available = append(available, u)
+		}
+	}
+	if len(available) == 0 {
+		available = urls
+	}
+	return b.inner.Pick(svc, available)
+}
@@ -0,0 +1,81 @@
+package source
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mercari/grpc-http-proxy"
+	"github.com/mercari/grpc-http-proxy/breaker"
+)
+
+// pickAll is a LoadBalancer stub that hands back whatever slice of urls it was given,
+// so tests can assert on exactly which URLs BreakerAware let through to it.
+type pickAll struct {
+	picked []proxy.ServiceURL
+}
+
+func (p *pickAll) Pick(svc string, urls []proxy.ServiceURL) proxy.ServiceURL {
+	p.picked = urls
+	return urls[0]
+}
+
+func TestBreakerAware_Pick_ExcludesOpenBreakers(t *testing.T) {
+	healthy := &url.URL{Scheme: "dns", Host: "10.0.0.1:50051"}
+	tripped := &url.URL{Scheme: "dns", Host: "10.0.0.2:50051"}
+
+	registry := breaker.NewRegistry(breaker.Policy{FailureThreshold: 1, OpenTimeout: time.Minute})
+	b := registry.For(tripped.String())
+	b.Allow()
+	b.Failure()
+	if !b.Open() {
+		t.Fatal("test setup: expected breaker for tripped to be open")
+	}
+
+	inner := &pickAll{}
+	lb := NewBreakerAware(inner, registry)
+	lb.Pick("svc", []proxy.ServiceURL{healthy, tripped})
+
+	if got, want := len(inner.picked), 1; got != want {
+		t.Fatalf("got %d urls passed to inner, want %d", got, want)
+	}
+	if inner.picked[0] != healthy {
+		t.Fatalf("got %v, want %v excluded from the tripped breaker's URL", inner.picked[0], healthy)
+	}
+}
+
+func TestBreakerAware_Pick_FallsBackToFullListWhenAllBreakersOpen(t *testing.T) {
+	a := &url.URL{Scheme: "dns", Host: "10.0.0.1:50051"}
+	c := &url.URL{Scheme: "dns", Host: "10.0.0.2:50051"}
+	urls := []proxy.ServiceURL{a, c}
+
+	registry := breaker.NewRegistry(breaker.Policy{FailureThreshold: 1, OpenTimeout: time.Minute})
+	for _, u := range urls {
+		b := registry.For(u.String())
+		b.Allow()
+		b.Failure()
+	}
+
+	inner := &pickAll{}
+	lb := NewBreakerAware(inner, registry)
+	lb.Pick("svc", urls)
+
+	if got, want := len(inner.picked), len(urls); got != want {
+		t.Fatalf("got %d urls passed to inner, want %d (should fall back to the unfiltered list so Pick still returns a URL)", got, want)
+	}
+}
+
+func TestBreakerAware_Pick_PassesEverythingThroughWhenNoBreakersOpen(t *testing.T) {
+	a := &url.URL{Scheme: "dns", Host: "10.0.0.1:50051"}
+	c := &url.URL{Scheme: "dns", Host: "10.0.0.2:50051"}
+	urls := []proxy.ServiceURL{a, c}
+
+	registry := breaker.NewRegistry(breaker.DefaultPolicy)
+	inner := &pickAll{}
+	lb := NewBreakerAware(inner, registry)
+	lb.Pick("svc", urls)
+
+	if got, want := len(inner.picked), len(urls); got != want {
+		t.Fatalf("got %d urls passed to inner, want %d", got, want)
+	}
+}
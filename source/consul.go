@@ -0,0 +1,136 @@
+package source
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	consulapi "github.com/hashicorp/consul/api"
+	"go.uber.org/zap"
+)
+
+// consulVersionTagPrefix marks the tag Consul service instances use to advertise which
+// gRPC service version they serve, e.g. "grpc-version=v2"
+const consulVersionTagPrefix = "grpc-version="
+
+// Consul discovers backend URLs for gRPC services from a Consul service catalog,
+// using blocking queries to stay up to date without polling. The gRPC service
+// version is taken from each instance's "grpc-version" tag; instances of the same
+// (service, version) are treated as equivalent backends.
+type Consul struct {
+	client  *consulapi.Client
+	records *Records
+	logger  *zap.Logger
+
+	// known tracks, per gRPC service, the backend URLs last pushed into records, so
+	// that a Consul catalog snapshot can be turned into SetRecord/RemoveRecord deltas
+	// instead of clearing and repopulating records on every poll.
+	known map[string]map[string]struct{}
+}
+
+// NewConsul creates a Consul discovery backend talking to the Consul agent at addr
+func NewConsul(addr string, lb LoadBalancer, l *zap.Logger) (*Consul, error) {
+	cfg := consulapi.DefaultConfig()
+	if addr != "" {
+		cfg.Address = addr
+	}
+	client, err := consulapi.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Consul{
+		client:  client,
+		records: NewRecords(lb),
+		logger:  l,
+		known:   make(map[string]map[string]struct{}),
+	}, nil
+}
+
+// Resolve resolves the FQDN for a backend providing the gRPC service specified
+func (c *Consul) Resolve(svc, version string) (*url.URL, error) {
+	r, err := c.records.Pick(svc, version)
+	if err != nil {
+		c.logger.Error("failed to resolve service",
+			zap.String("service", svc),
+			zap.String("version", version),
+			zap.String("err", err.Error()))
+		return nil, err
+	}
+	return r, nil
+}
+
+// Run starts watching the Consul catalog for changes, using blocking queries, until
+// stopCh is closed
+func (c *Consul) Run(stopCh <-chan struct{}) {
+	go c.watch(stopCh)
+}
+
+func (c *Consul) watch(stopCh <-chan struct{}) {
+	var index uint64
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+		services, meta, err := c.client.Catalog().Services(&consulapi.QueryOptions{
+			WaitIndex: index,
+			WaitTime:  5 * time.Minute,
+		})
+		if err != nil {
+			c.logger.Error("failed to list consul services", zap.String("err", err.Error()))
+			time.Sleep(time.Second)
+			continue
+		}
+		index = meta.LastIndex
+		for svc := range services {
+			c.sync(svc)
+		}
+	}
+}
+
+// sync resolves the current set of healthy instances of svc in Consul, and reconciles
+// it against records by diffing against what was pushed there on the previous sync
+func (c *Consul) sync(svc string) {
+	entries, _, err := c.client.Health().Service(svc, "", true, nil)
+	if err != nil {
+		c.logger.Error("failed to list consul service instances",
+			zap.String("service", svc),
+			zap.String("err", err.Error()))
+		return
+	}
+
+	seen := make(map[string]struct{}, len(entries))
+	for _, e := range entries {
+		version := versionFromConsulTags(e.Service.Tags)
+		u := &url.URL{Scheme: "dns", Host: fmt.Sprintf("%s:%d", e.Service.Address, e.Service.Port)}
+		key := version + "|" + u.String()
+		seen[key] = struct{}{}
+		if _, ok := c.known[svc][key]; !ok {
+			c.records.SetRecord(svc, version, u)
+		}
+	}
+	for key := range c.known[svc] {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		parts := strings.SplitN(key, "|", 2)
+		version, rawURL := parts[0], parts[1]
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			continue
+		}
+		c.records.RemoveRecord(svc, version, u)
+	}
+	c.known[svc] = seen
+}
+
+func versionFromConsulTags(tags []string) string {
+	for _, t := range tags {
+		if strings.HasPrefix(t, consulVersionTagPrefix) {
+			return strings.TrimPrefix(t, consulVersionTagPrefix)
+		}
+	}
+	return ""
+}
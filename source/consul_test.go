@@ -0,0 +1,34 @@
+package source
+
+import "testing"
+
+func TestVersionFromConsulTags(t *testing.T) {
+	cases := []struct {
+		name     string
+		tags     []string
+		expected string
+	}{
+		{
+			name:     "version tag present",
+			tags:     []string{"unrelated", "grpc-version=v2"},
+			expected: "v2",
+		},
+		{
+			name:     "no version tag",
+			tags:     []string{"unrelated"},
+			expected: "",
+		},
+		{
+			name:     "no tags",
+			tags:     nil,
+			expected: "",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := versionFromConsulTags(tc.tags), tc.expected; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package source
+
+import "net/url"
+
+// Discovery resolves gRPC services to backend URLs, using a registry-specific
+// mechanism to keep that mapping up to date. Static, Service (Kubernetes), Consul,
+// Etcd and DNS are all Discovery implementations, selected at startup by the
+// --discovery flag.
+type Discovery interface {
+	// Resolve resolves the FQDN for a backend providing the gRPC service specified
+	Resolve(svc, version string) (*url.URL, error)
+	// Run starts watching the backing registry for changes, until stopCh is closed
+	Run(stopCh <-chan struct{})
+}
@@ -0,0 +1,130 @@
+package source
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// dnsPollInterval is how often the watched services are re-resolved
+const dnsPollInterval = 30 * time.Second
+
+// DNS discovers backend URLs for gRPC services by periodically looking up the SRV
+// records for _<service>._tcp.<domain>. The gRPC service version is taken from the
+// corresponding TXT record, using the convention "version=<ver>"; instances with no
+// such record are registered under the blank version.
+type DNS struct {
+	domain   string
+	services []string
+	records  *Records
+	logger   *zap.Logger
+
+	// known tracks, per gRPC service, the (version, backend URL) pairs last pushed
+	// into records, keyed the same way as Consul's known map, so that a version
+	// change on an otherwise-unchanged SRV target is not mistaken for "already known"
+	known map[string]map[string]struct{}
+}
+
+// NewDNS creates a DNS discovery backend that periodically resolves services (gRPC
+// service names, used verbatim as SRV service names) under domain
+func NewDNS(domain string, services []string, lb LoadBalancer, l *zap.Logger) *DNS {
+	return &DNS{
+		domain:   domain,
+		services: services,
+		records:  NewRecords(lb),
+		logger:   l,
+		known:    make(map[string]map[string]struct{}),
+	}
+}
+
+// Resolve resolves the FQDN for a backend providing the gRPC service specified
+func (d *DNS) Resolve(svc, version string) (*url.URL, error) {
+	r, err := d.records.Pick(svc, version)
+	if err != nil {
+		d.logger.Error("failed to resolve service",
+			zap.String("service", svc),
+			zap.String("version", version),
+			zap.String("err", err.Error()))
+		return nil, err
+	}
+	return r, nil
+}
+
+// Run periodically re-resolves every watched service until stopCh is closed
+func (d *DNS) Run(stopCh <-chan struct{}) {
+	go d.watch(stopCh)
+}
+
+func (d *DNS) watch(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(dnsPollInterval)
+	defer ticker.Stop()
+	d.syncAll()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			d.syncAll()
+		}
+	}
+}
+
+func (d *DNS) syncAll() {
+	for _, svc := range d.services {
+		d.sync(svc)
+	}
+}
+
+func (d *DNS) sync(svc string) {
+	_, srvs, err := net.LookupSRV(svc, "tcp", d.domain)
+	if err != nil {
+		d.logger.Error("failed to look up SRV records",
+			zap.String("service", svc),
+			zap.String("err", err.Error()))
+		return
+	}
+	txts, err := net.LookupTXT(fmt.Sprintf("_%s._tcp.%s", svc, d.domain))
+	if err != nil {
+		d.logger.Error("failed to look up TXT records, leaving version unspecified",
+			zap.String("service", svc),
+			zap.String("err", err.Error()))
+	}
+	version := versionFromTXT(txts)
+
+	seen := make(map[string]struct{}, len(srvs))
+	for _, srv := range srvs {
+		u := &url.URL{Scheme: "dns", Host: fmt.Sprintf("%s:%d", strings.TrimSuffix(srv.Target, "."), srv.Port)}
+		key := version + "|" + u.String()
+		seen[key] = struct{}{}
+		if _, ok := d.known[svc][key]; !ok {
+			d.records.SetRecord(svc, version, u)
+		}
+	}
+	for key := range d.known[svc] {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		parts := strings.SplitN(key, "|", 2)
+		version, rawURL := parts[0], parts[1]
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			continue
+		}
+		d.records.RemoveRecord(svc, version, u)
+	}
+	d.known[svc] = seen
+}
+
+func versionFromTXT(txts []string) string {
+	const prefix = "version="
+	for _, t := range txts {
+		if strings.HasPrefix(t, prefix) {
+			return strings.TrimPrefix(t, prefix)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package source
+
+import "testing"
+
+func TestVersionFromTXT(t *testing.T) {
+	cases := []struct {
+		name     string
+		txts     []string
+		expected string
+	}{
+		{
+			name:     "version present",
+			txts:     []string{"unrelated=1", "version=v2"},
+			expected: "v2",
+		},
+		{
+			name:     "no version record",
+			txts:     []string{"unrelated=1"},
+			expected: "",
+		},
+		{
+			name:     "no records",
+			txts:     nil,
+			expected: "",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := versionFromTXT(tc.txts), tc.expected; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
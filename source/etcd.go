@@ -0,0 +1,182 @@
+package source
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	clientv3 "go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+	"go.uber.org/zap"
+)
+
+// Etcd discovers backend URLs for gRPC services from etcd v3, watching a configurable
+// key prefix of the form <prefix>/<service>/<version>/<id>, where each key's value is
+// the backend URL. Instances register under a lease, so a backend disappears from
+// Records shortly after it stops renewing it, without Etcd needing to see an explicit
+// delete.
+type Etcd struct {
+	client  *clientv3.Client
+	prefix  string
+	records *Records
+	logger  *zap.Logger
+
+	// applied tracks the (service, version, URL) currently registered for each etcd
+	// key, of the form <prefix>/<service>/<version>/<id>, so that re-applying an
+	// already-seen key (e.g. a lease re-announce) updates Records instead of
+	// appending a duplicate entry
+	applied map[string]appliedEtcdRecord
+}
+
+type appliedEtcdRecord struct {
+	svc, version, url string
+}
+
+// NewEtcd creates an Etcd discovery backend watching prefix on the given etcd cluster
+func NewEtcd(endpoints []string, prefix string, lb LoadBalancer, l *zap.Logger) (*Etcd, error) {
+	client, err := clientv3.New(clientv3.Config{Endpoints: endpoints})
+	if err != nil {
+		return nil, err
+	}
+	return &Etcd{
+		client:  client,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		records: NewRecords(lb),
+		logger:  l,
+		applied: make(map[string]appliedEtcdRecord),
+	}, nil
+}
+
+// Resolve resolves the FQDN for a backend providing the gRPC service specified
+func (e *Etcd) Resolve(svc, version string) (*url.URL, error) {
+	r, err := e.records.Pick(svc, version)
+	if err != nil {
+		e.logger.Error("failed to resolve service",
+			zap.String("service", svc),
+			zap.String("version", version),
+			zap.String("err", err.Error()))
+		return nil, err
+	}
+	return r, nil
+}
+
+// Run loads the current set of registrations under the watched prefix, then watches
+// it for further changes until stopCh is closed
+func (e *Etcd) Run(stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+	go e.watch(ctx)
+}
+
+// watch keeps the watched prefix loaded until ctx is canceled, restarting from a fresh
+// Get+Watch pair whenever the watch channel closes for any other reason (e.g. a
+// revision compaction or a connection reset), the same way Consul's watch re-issues its
+// blocking query after every iteration.
+func (e *Etcd) watch(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		e.watchOnce(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		e.logger.Error("etcd watch channel closed unexpectedly, restarting",
+			zap.String("prefix", e.prefix))
+	}
+}
+
+func (e *Etcd) watchOnce(ctx context.Context) {
+	watchOpts := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithPrevKV()}
+
+	get, err := e.client.Get(ctx, e.prefix, clientv3.WithPrefix())
+	if err != nil {
+		e.logger.Error("failed to list etcd registrations, watching from the current revision",
+			zap.String("prefix", e.prefix),
+			zap.String("err", err.Error()))
+	} else {
+		for _, kv := range get.Kvs {
+			e.apply(string(kv.Key), kv.Value)
+		}
+		watchOpts = append(watchOpts, clientv3.WithRev(get.Header.GetRevision()+1))
+	}
+
+	watchCh := e.client.Watch(ctx, e.prefix, watchOpts...)
+	for resp := range watchCh {
+		if err := resp.Err(); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			e.logger.Error("error watching etcd prefix",
+				zap.String("prefix", e.prefix),
+				zap.String("err", err.Error()))
+			continue
+		}
+		for _, ev := range resp.Events {
+			switch ev.Type {
+			case clientv3.EventTypePut:
+				e.apply(string(ev.Kv.Key), ev.Kv.Value)
+			case clientv3.EventTypeDelete:
+				e.remove(string(ev.Kv.Key), ev.PrevKv)
+			}
+		}
+	}
+}
+
+// apply registers the backend URL carried in value as an instance of the
+// (service, version) identified by key, of the form <prefix>/<service>/<version>/<id>.
+// If key was already applied with the same (service, version, URL) this is a no-op,
+// and if it was applied with a different one (e.g. the instance re-announced under a
+// new URL) the stale record is removed first, so a repeated PUT never leaves duplicate
+// entries in Records.
+func (e *Etcd) apply(key string, value []byte) {
+	svc, version, ok := e.parseKey(key)
+	if !ok {
+		return
+	}
+	u, err := url.Parse(string(value))
+	if err != nil {
+		e.logger.Error("failed to parse backend URL from etcd",
+			zap.String("key", key),
+			zap.String("err", err.Error()))
+		return
+	}
+
+	if prev, ok := e.applied[key]; ok {
+		if prev.svc == svc && prev.version == version && prev.url == u.String() {
+			return
+		}
+		if prevURL, err := url.Parse(prev.url); err == nil {
+			e.records.RemoveRecord(prev.svc, prev.version, prevURL)
+		}
+	}
+	e.records.SetRecord(svc, version, u)
+	e.applied[key] = appliedEtcdRecord{svc: svc, version: version, url: u.String()}
+}
+
+func (e *Etcd) remove(key string, prevKv *mvccpb.KeyValue) {
+	svc, version, ok := e.parseKey(key)
+	if !ok || prevKv == nil {
+		return
+	}
+	u, err := url.Parse(string(prevKv.Value))
+	if err != nil {
+		return
+	}
+	e.records.RemoveRecord(svc, version, u)
+	delete(e.applied, key)
+}
+
+func (e *Etcd) parseKey(key string) (svc, version string, ok bool) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(key, e.prefix), "/")
+	parts := strings.Split(rest, "/")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
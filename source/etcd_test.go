@@ -0,0 +1,90 @@
+package source
+
+import "testing"
+
+func newTestEtcd() *Etcd {
+	return &Etcd{
+		prefix:  "/grpc-http-proxy/services",
+		records: NewRecords(NewRoundRobin()),
+		logger:  nil,
+		applied: make(map[string]appliedEtcdRecord),
+	}
+}
+
+func TestEtcd_ApplyIsIdempotentForAnUnchangedKey(t *testing.T) {
+	e := newTestEtcd()
+	key := "/grpc-http-proxy/services/a/v1/instance-1"
+
+	e.apply(key, []byte("dns://10.0.0.1:50051"))
+	e.apply(key, []byte("dns://10.0.0.1:50051"))
+
+	r, err := e.records.Pick("a", "v1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := r.String(), "dns://10.0.0.1:50051"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got, want := len(e.records.m["a"]["v1"]), 1; got != want {
+		t.Fatalf("got %d entries for (a, v1), want %d (re-applying the same key must not duplicate it)", got, want)
+	}
+}
+
+func TestEtcd_ApplyReplacesThePreviousURLForAReannouncedKey(t *testing.T) {
+	e := newTestEtcd()
+	key := "/grpc-http-proxy/services/a/v1/instance-1"
+
+	e.apply(key, []byte("dns://10.0.0.1:50051"))
+	e.apply(key, []byte("dns://10.0.0.2:50051"))
+
+	entries := e.records.m["a"]["v1"]
+	if got, want := len(entries), 1; got != want {
+		t.Fatalf("got %d entries for (a, v1), want %d (re-announcing a key must replace, not add)", got, want)
+	}
+	if got, want := entries[0].String(), "dns://10.0.0.2:50051"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestEtcd_ParseKey(t *testing.T) {
+	e := &Etcd{prefix: "/grpc-http-proxy/services"}
+	cases := []struct {
+		name        string
+		key         string
+		wantSvc     string
+		wantVersion string
+		wantOK      bool
+	}{
+		{
+			name:        "well-formed key",
+			key:         "/grpc-http-proxy/services/a/v1/instance-1",
+			wantSvc:     "a",
+			wantVersion: "v1",
+			wantOK:      true,
+		},
+		{
+			name:   "missing instance id",
+			key:    "/grpc-http-proxy/services/a/v1",
+			wantOK: false,
+		},
+		{
+			name:   "unrelated key",
+			key:    "/something/else",
+			wantOK: false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, version, ok := e.parseKey(tc.key)
+			if ok != tc.wantOK {
+				t.Fatalf("got ok=%v, want %v", ok, tc.wantOK)
+			}
+			if !tc.wantOK {
+				return
+			}
+			if svc != tc.wantSvc || version != tc.wantVersion {
+				t.Fatalf("got (%q, %q), want (%q, %q)", svc, version, tc.wantSvc, tc.wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package source
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/mercari/grpc-http-proxy"
+)
+
+// LoadBalancer picks one of several equivalent backend URLs for a (service, version) pair.
+// Implementations must be safe for concurrent use, and urls is guaranteed to be non-empty.
+type LoadBalancer interface {
+	Pick(svc string, urls []proxy.ServiceURL) proxy.ServiceURL
+}
+
+// RoundRobin is a LoadBalancer that cycles through the backend URLs for a service in turn.
+// It keeps a cursor per service, guarded by its own mutex so that picking a backend never
+// contends with Records.recordsMu.
+type RoundRobin struct {
+	cursorMu sync.Mutex
+	cursors  map[string]int
+}
+
+// NewRoundRobin creates a new RoundRobin load balancer
+func NewRoundRobin() *RoundRobin {
+	return &RoundRobin{
+		cursors: make(map[string]int),
+	}
+}
+
+// Pick returns the next URL in turn for svc
+func (b *RoundRobin) Pick(svc string, urls []proxy.ServiceURL) proxy.ServiceURL {
+	b.cursorMu.Lock()
+	defer b.cursorMu.Unlock()
+	i := b.cursors[svc] % len(urls)
+	b.cursors[svc] = i + 1
+	return urls[i]
+}
+
+// Random is a LoadBalancer that picks a backend URL uniformly at random.
+type Random struct{}
+
+// NewRandom creates a new Random load balancer
+func NewRandom() *Random {
+	return &Random{}
+}
+
+// Pick returns a uniformly random URL out of urls
+func (b *Random) Pick(svc string, urls []proxy.ServiceURL) proxy.ServiceURL {
+	return urls[rand.Int()%len(urls)]
+}
+
+// WeightedRandom is a LoadBalancer that picks a backend URL at random, in proportion to
+// a per-URL weight. URLs are looked up in Weights by their host:port, not their full
+// URL string, since every discovery backend constructs backend URLs with its own
+// scheme (e.g. "dns"), which operators configuring Weights by hand would have no
+// reason to know or type. URLs with no entry in Weights (or a non-positive one) are
+// weighted 1.
+type WeightedRandom struct {
+	Weights map[string]int
+}
+
+// NewWeightedRandom creates a new WeightedRandom load balancer using weights, keyed by
+// backend host:port
+func NewWeightedRandom(weights map[string]int) *WeightedRandom {
+	return &WeightedRandom{Weights: weights}
+}
+
+// Pick returns a URL out of urls, chosen at random in proportion to its configured weight
+func (b *WeightedRandom) Pick(svc string, urls []proxy.ServiceURL) proxy.ServiceURL {
+	weights := make([]int, len(urls))
+	total := 0
+	for i, u := range urls {
+		w := b.Weights[u.Host]
+		if w <= 0 {
+			w = 1
+		}
+		weights[i] = w
+		total += w
+	}
+	r := rand.Int() % total
+	for i, w := range weights {
+		if r < w {
+			return urls[i]
+		}
+		r -= w
+	}
+	return urls[len(urls)-1]
+}
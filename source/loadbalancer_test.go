@@ -0,0 +1,86 @@
+package source
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/mercari/grpc-http-proxy"
+)
+
+func TestRoundRobin_Pick(t *testing.T) {
+	urls := []proxy.ServiceURL{
+		parseURL(t, "a"),
+		parseURL(t, "b"),
+		parseURL(t, "c"),
+	}
+	b := NewRoundRobin()
+	for i, want := range urls {
+		if got := b.Pick("svc", urls); got != want {
+			t.Fatalf("pick %d: got %v, want %v", i, got, want)
+		}
+	}
+	// the cursor wraps back around to the first URL
+	if got, want := b.Pick("svc", urls), urls[0]; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestRoundRobin_PicksIndependentlyPerService(t *testing.T) {
+	urls := []proxy.ServiceURL{parseURL(t, "a"), parseURL(t, "b")}
+	b := NewRoundRobin()
+	b.Pick("svc-a", urls)
+	if got, want := b.Pick("svc-b", urls), urls[0]; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestRandom_Pick(t *testing.T) {
+	urls := []proxy.ServiceURL{parseURL(t, "a")}
+	b := NewRandom()
+	if got, want := b.Pick("svc", urls), urls[0]; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestWeightedRandom_Pick(t *testing.T) {
+	a := &url.URL{Scheme: "dns", Host: "10.0.0.1:50051"}
+	b := &url.URL{Scheme: "dns", Host: "10.0.0.2:50051"}
+	urls := []proxy.ServiceURL{a, b}
+	lb := NewWeightedRandom(map[string]int{a.Host: 0, b.Host: 1})
+	picked := make(map[proxy.ServiceURL]int)
+	for i := 0; i < 100; i++ {
+		picked[lb.Pick("svc", urls)]++
+	}
+	if picked[a] == 0 {
+		t.Fatalf("weight-0 URL %v was never picked in 100 picks, want it weighted 1", a)
+	}
+	if picked[b] == 0 {
+		t.Fatalf("weight-1 URL %v was never picked in 100 picks", b)
+	}
+}
+
+func TestWeightedRandom_UnlistedURLDefaultsToWeightOne(t *testing.T) {
+	only := &url.URL{Scheme: "dns", Host: "10.0.0.1:50051"}
+	lb := NewWeightedRandom(nil)
+	if got, want := lb.Pick("svc", []proxy.ServiceURL{only}), only; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// TestWeightedRandom_WeightsKeyedByHostNotFullURL guards against regressing into
+// keying Weights by the full URL string, which every real discovery backend's "dns"
+// scheme would make impossible for operators to match by hand.
+func TestWeightedRandom_WeightsKeyedByHostNotFullURL(t *testing.T) {
+	heavy := &url.URL{Scheme: "dns", Host: "10.0.0.1:50051"}
+	light := &url.URL{Scheme: "dns", Host: "10.0.0.2:50051"}
+	lb := NewWeightedRandom(map[string]int{"10.0.0.1:50051": 1000})
+	lightPicks := 0
+	for i := 0; i < 100; i++ {
+		if lb.Pick("svc", []proxy.ServiceURL{heavy, light}) == light {
+			lightPicks++
+		}
+	}
+	if lightPicks >= 10 {
+		t.Fatalf("got %d of 100 picks for the unlisted URL, want almost none (weight keyed by host:port should favor %v)", lightPicks, heavy)
+	}
+}
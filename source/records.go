@@ -11,10 +11,12 @@ import (
 type versions map[string][]proxy.ServiceURL
 
 // Records contains mappings from a gRPC service to upstream hosts
-// It holds one upstream for each service version
+// It holds one or more equivalent upstreams for each service version, and uses a
+// LoadBalancer to pick one of them when there is more than one
 type Records struct {
-	m     map[string]versions
-	mutex sync.RWMutex
+	m         map[string]versions
+	recordsMu sync.RWMutex
+	lb        LoadBalancer
 }
 
 func serviceUnresolvable(svc string) *errors.Error {
@@ -39,34 +41,28 @@ func versionNotSpecified(svc string) *errors.Error {
 	}
 }
 
-func versionUndecidable(svc string) *errors.Error {
-	return &errors.Error{
-		Code: errors.VersionUndecidable,
-		Message: fmt.Sprintf("Multiple possible backends found for the gRPC service %s. "+
-			"Add annotations to distinguish versions", svc),
-	}
-}
-
-// NewRecords creates an empty mapping
-func NewRecords() *Records {
+// NewRecords creates an empty mapping that picks between multiple equivalent
+// backends for the same (service, version) pair using lb
+func NewRecords(lb LoadBalancer) *Records {
 	m := make(map[string]versions)
 	return &Records{
-		m:     m,
-		mutex: sync.RWMutex{},
+		m:  m,
+		lb: lb,
 	}
 }
 
 // ClearRecords clears all mappings
 func (r *Records) ClearRecords() {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	r.recordsMu.Lock()
+	defer r.recordsMu.Unlock()
 	r.m = make(map[string]versions)
 }
 
-// GetRecord gets a records of the specified (service, version) pair
-func (r *Records) GetRecord(svc, version string) (proxy.ServiceURL, error) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+// Pick resolves the specified (service, version) pair to its set of equivalent
+// backend URLs, and returns one of them as chosen by the Records' LoadBalancer
+func (r *Records) Pick(svc, version string) (proxy.ServiceURL, error) {
+	r.recordsMu.RLock()
+	defer r.recordsMu.RUnlock()
 	vs, ok := r.m[svc]
 	if !ok {
 		return nil, serviceUnresolvable(svc)
@@ -75,29 +71,23 @@ func (r *Records) GetRecord(svc, version string) (proxy.ServiceURL, error) {
 		if len(vs) != 1 {
 			return nil, versionNotSpecified(svc)
 		}
-		for _, entries := range vs {
-			if len(entries) != 1 {
-				return nil, versionUndecidable(svc)
-			}
-			return entries[0], nil // this returns the first (and only) ServiceURL
+		for v := range vs {
+			version = v
 		}
 	}
 	entries, ok := vs[version]
-	if !ok {
+	if !ok || len(entries) == 0 {
 		return nil, versionNotFound(svc, version)
 	}
-	if len(entries) != 1 {
-		return nil, versionUndecidable(svc)
-	}
-	return entries[0], nil
+	return r.lb.Pick(svc, entries), nil
 }
 
 // SetRecord sets the backend service URL for the specifiec (service, version) pair.
 // When successful, true will be returned.
 // This fails if the URL for the blank version ("") is to be overwritten, and invalidates that entry.
 func (r *Records) SetRecord(svc, version string, url proxy.ServiceURL) bool {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	r.recordsMu.Lock()
+	defer r.recordsMu.Unlock()
 	if _, ok := r.m[svc]; !ok {
 		r.m[svc] = make(map[string][]proxy.ServiceURL)
 	}
@@ -110,8 +100,8 @@ func (r *Records) SetRecord(svc, version string, url proxy.ServiceURL) bool {
 
 // RemoveRecord removes a record of the specified (service, version) pair
 func (r *Records) RemoveRecord(svc, version string, url proxy.ServiceURL) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	r.recordsMu.Lock()
+	defer r.recordsMu.Unlock()
 
 	vs, ok := r.m[svc]
 	if !ok {
@@ -138,16 +128,16 @@ func (r *Records) RemoveRecord(svc, version string, url proxy.ServiceURL) {
 
 // IsServiceUnique checks if there is only one version of a service
 func (r *Records) IsServiceUnique(svc string) bool {
-	r.mutex.RLock()
+	r.recordsMu.RLock()
 	b := len(r.m[svc]) == 1
-	r.mutex.RUnlock()
+	r.recordsMu.RUnlock()
 	return b
 }
 
 // RecordExists checks if a record exists
 func (r *Records) RecordExists(svc, version string) bool {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	r.recordsMu.RLock()
+	defer r.recordsMu.RUnlock()
 	vs, ok := r.m[svc]
 	if !ok {
 		return false
@@ -0,0 +1,179 @@
+package source
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// k8sVersionAnnotation marks the annotation a Kubernetes Service carries to advertise
+// the gRPC service version served by its endpoints, e.g. "grpc-version: v2". Services
+// with no such annotation are registered under the blank version. Kubernetes does not
+// copy a Service's annotations onto the Endpoints object it maintains for it, so this
+// is looked up from the Service itself, not from the Endpoints being synced.
+const k8sVersionAnnotation = "grpc-version"
+
+// k8sGRPCPortName is the Endpoints port name Service looks for when an Endpoints object
+// exposes more than one port; with a single port, that one is used regardless of its name.
+const k8sGRPCPortName = "grpc"
+
+// Service discovers backend URLs for gRPC services from Kubernetes Endpoints, watching
+// every Endpoints object in namespace (all namespaces if namespace is ""). Every ready
+// address in an Endpoints' subsets becomes its own backend URL, so a LoadBalancer can
+// pick between a Service's individual pods directly instead of going through
+// kube-proxy's own Service-level load balancing.
+type Service struct {
+	client    kubernetes.Interface
+	namespace string
+	records   *Records
+	logger    *zap.Logger
+
+	// known tracks, per gRPC service, the (version, backend URL) pairs last pushed
+	// into records, keyed the same way as Consul's known map
+	known map[string]map[string]struct{}
+}
+
+// NewService creates a Service discovery backend watching Endpoints in namespace (all
+// namespaces if namespace is "") on the given Kubernetes client
+func NewService(client kubernetes.Interface, namespace string, l *zap.Logger, lb LoadBalancer) *Service {
+	return &Service{
+		client:    client,
+		namespace: namespace,
+		records:   NewRecords(lb),
+		logger:    l,
+		known:     make(map[string]map[string]struct{}),
+	}
+}
+
+// Resolve resolves the FQDN for a backend providing the gRPC service specified
+func (s *Service) Resolve(svc, version string) (*url.URL, error) {
+	r, err := s.records.Pick(svc, version)
+	if err != nil {
+		s.logger.Error("failed to resolve service",
+			zap.String("service", svc),
+			zap.String("version", version),
+			zap.String("err", err.Error()))
+		return nil, err
+	}
+	return r, nil
+}
+
+// Run starts watching Endpoints for changes until stopCh is closed
+func (s *Service) Run(stopCh <-chan struct{}) {
+	_, controller := cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return s.client.CoreV1().Endpoints(s.namespace).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return s.client.CoreV1().Endpoints(s.namespace).Watch(options)
+			},
+		},
+		&corev1.Endpoints{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { s.sync(obj) },
+			UpdateFunc: func(_, obj interface{}) { s.sync(obj) },
+			DeleteFunc: s.remove,
+		},
+	)
+	go controller.Run(stopCh)
+}
+
+// sync reconciles the addresses currently in an Endpoints object against records,
+// diffing against what was pushed there on the previous sync of the same service
+func (s *Service) sync(obj interface{}) {
+	ep, ok := obj.(*corev1.Endpoints)
+	if !ok {
+		return
+	}
+	svc := ep.Name
+	version := s.serviceVersion(ep.Namespace, svc)
+
+	seen := make(map[string]struct{})
+	for _, subset := range ep.Subsets {
+		port := grpcPort(subset.Ports)
+		if port == 0 {
+			continue
+		}
+		for _, addr := range subset.Addresses {
+			u := &url.URL{Scheme: "dns", Host: fmt.Sprintf("%s:%d", addr.IP, port)}
+			key := version + "|" + u.String()
+			seen[key] = struct{}{}
+			if _, ok := s.known[svc][key]; !ok {
+				s.records.SetRecord(svc, version, u)
+			}
+		}
+	}
+	s.removeStale(svc, seen)
+	s.known[svc] = seen
+}
+
+// serviceVersion reads the gRPC version the Service named svc in namespace advertises
+// via k8sVersionAnnotation
+func (s *Service) serviceVersion(namespace, svc string) string {
+	k8sSvc, err := s.client.CoreV1().Services(namespace).Get(svc, metav1.GetOptions{})
+	if err != nil {
+		s.logger.Error("failed to look up service for its gRPC version annotation",
+			zap.String("service", svc),
+			zap.String("err", err.Error()))
+		return ""
+	}
+	return k8sSvc.Annotations[k8sVersionAnnotation]
+}
+
+// remove drops every address of an Endpoints object that was deleted
+func (s *Service) remove(obj interface{}) {
+	ep, ok := obj.(*corev1.Endpoints)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		ep, ok = tombstone.Obj.(*corev1.Endpoints)
+		if !ok {
+			return
+		}
+	}
+	s.removeStale(ep.Name, nil)
+	delete(s.known, ep.Name)
+}
+
+// removeStale removes, from records, every (version, URL) pair known for svc that is
+// not present in seen
+func (s *Service) removeStale(svc string, seen map[string]struct{}) {
+	for key := range s.known[svc] {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		parts := strings.SplitN(key, "|", 2)
+		version, rawURL := parts[0], parts[1]
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			continue
+		}
+		s.records.RemoveRecord(svc, version, u)
+	}
+}
+
+// grpcPort picks the port an Endpoints subset serves gRPC on: the only port if there is
+// just one, or the one named "grpc" if there are several
+func grpcPort(ports []corev1.EndpointPort) int32 {
+	if len(ports) == 1 {
+		return ports[0].Port
+	}
+	for _, p := range ports {
+		if p.Name == k8sGRPCPortName {
+			return p.Port
+		}
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package source
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGRPCPort(t *testing.T) {
+	cases := []struct {
+		name     string
+		ports    []corev1.EndpointPort
+		expected int32
+	}{
+		{
+			name:     "single port, any name",
+			ports:    []corev1.EndpointPort{{Name: "http", Port: 8080}},
+			expected: 8080,
+		},
+		{
+			name: "multiple ports, grpc named",
+			ports: []corev1.EndpointPort{
+				{Name: "metrics", Port: 9090},
+				{Name: "grpc", Port: 50051},
+			},
+			expected: 50051,
+		},
+		{
+			name: "multiple ports, none named grpc",
+			ports: []corev1.EndpointPort{
+				{Name: "metrics", Port: 9090},
+				{Name: "http", Port: 8080},
+			},
+			expected: 0,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := grpcPort(tc.ports), tc.expected; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+		})
+	}
+}
@@ -2,35 +2,59 @@ package source
 
 import (
 	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"go.uber.org/zap"
 )
 
-// Static provides service discovery with a static mapping of services and their backend FQDNs
+// debounceInterval coalesces bursts of filesystem events (e.g. the sequence of
+// renames a Kubernetes ConfigMap update produces) into a single reload.
+const debounceInterval = 100 * time.Millisecond
+
+// Static provides service discovery with a static mapping of services and their backend
+// FQDNs, read from a YAML file. The mapping file is watched for changes and hot-reloaded;
+// in-flight calls to Resolve always see either the old or the new mapping, never a
+// half-updated one.
 type Static struct {
-	records *Records
-	logger  *zap.Logger
+	records     atomic.Value // holds *Records
+	logger      *zap.Logger
+	mappingFile string
+	lb          LoadBalancer
+	watcher     *fsnotify.Watcher
+	closeCh     chan struct{}
 }
 
-// NewStatic creates a new Static
-func NewStatic(l *zap.Logger, mappingFile string) *Static {
+// NewStatic creates a new Static, using lb to pick between multiple backend URLs
+// configured for the same (service, version) pair
+func NewStatic(l *zap.Logger, mappingFile string, lb LoadBalancer) *Static {
 	local := &Static{
-		logger: l,
+		logger:      l,
+		mappingFile: mappingFile,
+		lb:          lb,
+		closeCh:     make(chan struct{}),
 	}
-	r, err := NewRecordsFromYAML(mappingFile)
+	r, err := NewRecordsFromYAML(mappingFile, lb)
 	if err != nil {
 		local.logger.Error("failed to initialize records from yaml",
 			zap.String("err", err.Error()))
-		local.records = NewRecords()
-		return local
+		r = NewRecords(lb)
+	}
+	local.records.Store(r)
+
+	if err := local.watch(); err != nil {
+		local.logger.Error("failed to watch mapping file for changes",
+			zap.String("file", mappingFile),
+			zap.String("err", err.Error()))
 	}
-	local.records = r
 	return local
 }
 
 // Resolve resolves the FQDN for a backend providing the gRPC service specified
 func (l *Static) Resolve(svc, version string) (*url.URL, error) {
-	r, err := l.records.GetRecord(svc, version)
+	r, err := l.current().Pick(svc, version)
 	if err != nil {
 		l.logger.Error("failed to resolve service",
 			zap.String("service", svc),
@@ -40,3 +64,127 @@ func (l *Static) Resolve(svc, version string) (*url.URL, error) {
 	}
 	return r, nil
 }
+
+// Run watches the mapping file for changes until stopCh is closed. The watch is
+// actually started in NewStatic; Run exists so Static satisfies Discovery alongside
+// the registry-backed sources, which only start watching once Run is called.
+func (l *Static) Run(stopCh <-chan struct{}) {
+	go func() {
+		<-stopCh
+		if err := l.Close(); err != nil {
+			l.logger.Error("failed to close mapping file watcher", zap.String("err", err.Error()))
+		}
+	}()
+}
+
+// Close stops watching the mapping file for changes
+func (l *Static) Close() error {
+	if l.watcher == nil {
+		return nil
+	}
+	close(l.closeCh)
+	return l.watcher.Close()
+}
+
+func (l *Static) current() *Records {
+	return l.records.Load().(*Records)
+}
+
+// watch starts a goroutine watching mappingFile for changes. If mappingFile is a
+// symlink, the containing directory is watched instead, so that the atomic symlink
+// swap Kubernetes uses to apply ConfigMap updates is detected.
+func (l *Static) watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	target := l.mappingFile
+	if resolved, err := filepath.EvalSymlinks(l.mappingFile); err == nil && resolved != l.mappingFile {
+		target = filepath.Dir(l.mappingFile)
+	}
+	if err := w.Add(target); err != nil {
+		w.Close()
+		return err
+	}
+
+	l.watcher = w
+	go l.watchLoop()
+	return nil
+}
+
+func (l *Static) watchLoop() {
+	var debounce *time.Timer
+	for {
+		select {
+		case <-l.closeCh:
+			if debounce != nil {
+				debounce.Stop()
+			}
+			return
+		case event, ok := <-l.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if debounce != nil {
+				debounce.Stop()
+			}
+			debounce = time.AfterFunc(debounceInterval, l.reload)
+		case err, ok := <-l.watcher.Errors:
+			if !ok {
+				return
+			}
+			l.logger.Error("error watching mapping file",
+				zap.String("file", l.mappingFile),
+				zap.String("err", err.Error()))
+		}
+	}
+}
+
+// reload re-parses the mapping file and atomically swaps it in. If the file is
+// currently invalid, the previous records are left in place.
+func (l *Static) reload() {
+	next, err := NewRecordsFromYAML(l.mappingFile, l.lb)
+	if err != nil {
+		l.logger.Error("failed to reload mapping file, keeping previous records",
+			zap.String("file", l.mappingFile),
+			zap.String("err", err.Error()))
+		return
+	}
+	prev := l.current()
+	added, removed := diffServices(prev, next)
+	l.records.Store(next)
+	l.logger.Info("reloaded mapping file",
+		zap.String("file", l.mappingFile),
+		zap.Strings("added", added),
+		zap.Strings("removed", removed),
+	)
+}
+
+// diffServices returns the services present in next but not prev, and those present
+// in prev but not next
+func diffServices(prev, next *Records) (added, removed []string) {
+	prev.recordsMu.RLock()
+	prevSvcs := make(map[string]struct{}, len(prev.m))
+	for svc := range prev.m {
+		prevSvcs[svc] = struct{}{}
+	}
+	prev.recordsMu.RUnlock()
+
+	next.recordsMu.RLock()
+	defer next.recordsMu.RUnlock()
+	for svc := range next.m {
+		if _, ok := prevSvcs[svc]; ok {
+			delete(prevSvcs, svc)
+		} else {
+			added = append(added, svc)
+		}
+	}
+	for svc := range prevSvcs {
+		removed = append(removed, svc)
+	}
+	return added, removed
+}
@@ -0,0 +1,74 @@
+package source
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mercari/grpc-http-proxy/log"
+)
+
+func TestStatic_HotReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static-hot-reload")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mappingFile := filepath.Join(dir, "mapping.yaml")
+	if err := ioutil.WriteFile(mappingFile, []byte("a:\n  v1: a.v1\n"), 0644); err != nil {
+		t.Fatalf("failed to write mapping file: %s", err)
+	}
+
+	logger := log.NewDiscard()
+	static := NewStatic(logger, mappingFile, NewRoundRobin())
+	defer static.Close()
+
+	if !static.current().RecordExists("a", "v1") {
+		t.Fatal("expected initial mapping to be loaded")
+	}
+	if static.current().RecordExists("b", "v1") {
+		t.Fatal("did not expect b/v1 to exist before reload")
+	}
+
+	updated := "a:\n  v1: a.v1\nb:\n  v1: b.v1\n"
+	if err := ioutil.WriteFile(mappingFile, []byte(updated), 0644); err != nil {
+		t.Fatalf("failed to rewrite mapping file: %s", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !static.current().RecordExists("b", "v1") {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for hot reload to pick up the updated mapping file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStatic_InvalidReloadKeepsPreviousRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static-invalid-reload")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	mappingFile := filepath.Join(dir, "mapping.yaml")
+	if err := ioutil.WriteFile(mappingFile, []byte("a:\n  v1: a.v1\n"), 0644); err != nil {
+		t.Fatalf("failed to write mapping file: %s", err)
+	}
+
+	logger := log.NewDiscard()
+	static := NewStatic(logger, mappingFile, NewRoundRobin())
+	defer static.Close()
+
+	if err := ioutil.WriteFile(mappingFile, []byte("not: [valid"), 0644); err != nil {
+		t.Fatalf("failed to corrupt mapping file: %s", err)
+	}
+
+	time.Sleep(200 * time.Millisecond) // give the debounced reload a chance to run
+	if !static.current().RecordExists("a", "v1") {
+		t.Fatal("expected previous records to survive an invalid reload")
+	}
+}
@@ -44,8 +44,8 @@ func TestNewStatic(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			logger := log.NewDiscard()
-			static := NewStatic(logger, tc.yamlFile)
-			if got, want := static.records.m, tc.expected; !reflect.DeepEqual(got, want) {
+			static := NewStatic(logger, tc.yamlFile, NewRoundRobin())
+			if got, want := static.current().m, tc.expected; !reflect.DeepEqual(got, want) {
 				t.Fatalf("got %v, want %v", got, want)
 			}
 		})
@@ -86,12 +86,13 @@ func TestStatic_Resolve(t *testing.T) {
 			},
 		},
 		recordsMu: sync.RWMutex{},
+		lb:        NewRoundRobin(),
 	}
 	logger := log.NewDiscard()
 	local := &Static{
-		records: &r,
-		logger:  logger,
+		logger: logger,
 	}
+	local.records.Store(&r)
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			u, err := local.Resolve(tc.service, tc.version)
@@ -0,0 +1,9 @@
+package proxy
+
+// GRPCResponseWriter is implemented by the HTTP layer so that a streaming call can
+// flush response messages to the client as they arrive, instead of buffering an
+// entire RPC's worth of messages in memory before writing anything.
+type GRPCResponseWriter interface {
+	// WriteMessage flushes a single decoded response message downstream.
+	WriteMessage(GRPCResponse) error
+}